@@ -0,0 +1,25 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// Net covers libp2p/network-level RPC methods. It currently only has the
+// methods added alongside node/modules.NATPortMap; the rest of the node's
+// network-level surface (NetConnectedness, NetPeers, ...) is out of scope
+// here.
+type Net interface {
+	// NetNATStatus reports the current state of the node's UPnP/NAT-PMP
+	// port mapping, if any.
+	NetNATStatus(ctx context.Context) (NatInfo, error)
+}
+
+// NatInfo is returned by NetNATStatus.
+type NatInfo struct {
+	Active bool
+	// GatewayType is "UPnP" or "NAT-PMP", as reported by go-nat.
+	GatewayType string
+	ExternalIP  string
+	LeaseExpiry time.Time
+}
@@ -2,9 +2,102 @@ package api
 
 import (
 	"context"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/lotus/chain/types"
 )
 
 type Sentinel interface {
-	WatchStop(context.Context) error
-	WatchStart(context.Context) error
+	// WatchStart begins indexing live tipsets as they're synced. tasks
+	// selects which indexers to run (see the sentinel package's Tasks
+	// registry); storage is a "csv://<dir>" path or a Postgres DSN.
+	WatchStart(ctx context.Context, tasks []string, storage string) error
+	WatchStop(ctx context.Context) error
+	// WalkChain backfills tasks over [from, to] into storage by walking the
+	// chain store without waiting for new tipsets to arrive, independent of
+	// any currently-active WatchStart. The walk follows parent pointers
+	// newest-to-oldest, so from must be >= to: from is the newer, higher end
+	// of the range, to the older, lower end.
+	WalkChain(ctx context.Context, from, to abi.ChainEpoch, tasks []string, storage string) error
+	WatchStatus(ctx context.Context) (SentinelWatchStatus, error)
+
+	// TipSetStream streams every applied/reverted tipset as it's observed.
+	// opts.Confidence is not applied: every subscriber shares one streaming
+	// hub and receives tipsets at that hub's depth.
+	TipSetStream(ctx context.Context, opts SentinelStreamOpts) (<-chan SentinelTipSetEvent, error)
+	// MessageStream streams messages matching filter as their containing
+	// tipsets are applied/reverted.
+	MessageStream(ctx context.Context, filter SentinelMessageFilter) (<-chan SentinelMessageEvent, error)
+	// StateChangeStream streams state root changes for actors as their
+	// containing tipsets are applied/reverted.
+	StateChangeStream(ctx context.Context, actors []address.Address) (<-chan SentinelStateDelta, error)
+}
+
+// SentinelTaskStatus reports the progress of a single indexing task.
+type SentinelTaskStatus struct {
+	Task       string
+	LastHeight abi.ChainEpoch
+	Running    bool
+	Lag        abi.ChainEpoch
+	Error      string
+}
+
+// SentinelStreamStatus reports backpressure on one of the streaming
+// subscriptions below: how many events were dropped because the consumer
+// wasn't keeping up.
+type SentinelStreamStatus struct {
+	Stream  string // "tipset", "message" or "state"
+	Dropped uint64
+}
+
+// SentinelWatchStatus is the aggregate status returned by WatchStatus.
+type SentinelWatchStatus struct {
+	Tasks   []SentinelTaskStatus
+	Streams []SentinelStreamStatus
+}
+
+// SentinelStreamOpts configures a TipSetStream subscription.
+type SentinelStreamOpts struct {
+	// Confidence is a number of epochs to wait before emitting a tipset. It
+	// is accepted but not applied by TipSetStream.
+	Confidence int
+}
+
+// SentinelMessageFilter restricts a MessageStream subscription to messages
+// to/from a set of addresses. A filter with both fields empty matches every
+// message.
+type SentinelMessageFilter struct {
+	From []address.Address
+	To   []address.Address
+}
+
+// SentinelTipSetEvent is emitted by TipSetStream.
+type SentinelTipSetEvent struct {
+	TipSetKey types.TipSetKey
+	Height    abi.ChainEpoch
+	Reverted  bool
+	// Payload is the CBOR-encoded block headers of the tipset.
+	Payload []byte
+}
+
+// SentinelMessageEvent is emitted by MessageStream.
+type SentinelMessageEvent struct {
+	TipSetKey types.TipSetKey
+	Height    abi.ChainEpoch
+	Reverted  bool
+	// Payload is the CBOR-encoded message.
+	Payload []byte
+}
+
+// SentinelStateDelta is emitted by StateChangeStream.
+type SentinelStateDelta struct {
+	TipSetKey types.TipSetKey
+	Height    abi.ChainEpoch
+	Actor     address.Address
+	StateRoot cid.Cid
+	Reverted  bool
 }
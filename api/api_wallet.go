@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// WalletAPI is the interface satisfied by every wallet backend (Local,
+// Remote, Ledger, Seed, ...) and by MultiWallet, which fans requests out
+// across whichever of them are configured.
+type WalletAPI interface {
+	WalletNew(ctx context.Context, keyType types.KeyType) (address.Address, error)
+	WalletHas(ctx context.Context, address address.Address) (bool, error)
+	WalletList(ctx context.Context) ([]address.Address, error)
+	WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta MsgMeta) (*crypto.Signature, error)
+	WalletExport(ctx context.Context, address address.Address, password string) (*types.KeyInfo, error)
+	WalletImport(ctx context.Context, info *types.KeyInfo) (address.Address, error)
+	WalletDelete(ctx context.Context, address address.Address, pass string) error
+	WalletChangePasswd(ctx context.Context, newPasswd string) (bool, error)
+	DeleteKey2(addr address.Address) error
+	WalletClearPasswd(ctx context.Context) (bool, error)
+	WalletIsLock(ctx context.Context) (bool, error)
+	WalletLock(ctx context.Context) error
+	WalletUnlock(ctx context.Context, password string) error
+	WalletSignMessage2(ctx context.Context, k address.Address, msg *types.Message, passwd string) (*types.SignedMessage, error)
+}
+
+// WalletSyncStatusAPI is implemented by wallet backends that track their own
+// view of how far behind chain head their addresses and nonces are. It is
+// deliberately not part of WalletAPI: a backend that doesn't maintain that
+// view itself (Local, Ledger sign against whatever nonce the caller gives
+// them) or doesn't yet proxy it (Remote; see chain/wallet/remotewallet)
+// simply doesn't implement it, and MultiWallet skips it when fanning
+// WalletSyncStatus/WalletSyncedUpdates out across configured backends.
+type WalletSyncStatusAPI interface {
+	// WalletSyncStatus reports how far behind chain head the wallet's view
+	// of addresses and nonces currently is. Callers that are about to sign
+	// a message that depends on a fresh nonce (deal-making, precommit,
+	// ...) should wait for a synced status, or better, consume
+	// WalletSyncedUpdates, before calling WalletSign.
+	WalletSyncStatus(ctx context.Context) (WalletSyncStatus, error)
+	// WalletSyncedUpdates returns a channel of WalletSyncStatus pushed
+	// every time the wallet's view of chain head or any address's scanned
+	// height changes. The channel is closed when ctx is done.
+	WalletSyncedUpdates(ctx context.Context) (<-chan WalletSyncStatus, error)
+}
+
+// MsgMeta is metadata about a message to help with signing, e.g. to allow
+// a wallet to display message info to the user.
+type MsgMeta struct {
+	Type string
+	Extra []byte
+}
+
+// AddressSyncStatus reports how far a single address's wallet-side view has
+// been scanned, independent of whether the node itself has synced further.
+type AddressSyncStatus struct {
+	Address          address.Address
+	LastScannedEpoch int64
+	// NoncePending is true while the wallet is still reconciling its next
+	// nonce for this address against the mpool.
+	NoncePending bool
+}
+
+// WalletSyncStatus is returned by WalletSyncStatus and streamed by
+// WalletSyncedUpdates.
+type WalletSyncStatus struct {
+	// HeadHeight is the chain head tipset height the wallet has observed.
+	HeadHeight int64
+	Addresses  []AddressSyncStatus
+}
+
+// Synced reports whether every tracked address has scanned up to HeadHeight
+// and has no pending nonce reconciliation.
+func (s WalletSyncStatus) Synced() bool {
+	for _, a := range s.Addresses {
+		if a.LastScannedEpoch < s.HeadHeight || a.NoncePending {
+			return false
+		}
+	}
+	return true
+}
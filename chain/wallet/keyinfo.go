@@ -0,0 +1,49 @@
+package wallet
+
+import (
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	gocrypto "github.com/filecoin-project/go-crypto"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// addressFromKeyInfo derives the public Filecoin address for a raw private
+// key, mirroring the key type switch used throughout the wallet backends.
+func addressFromKeyInfo(ki *types.KeyInfo) (address.Address, error) {
+	switch ki.Type {
+	case types.KTSecp256k1:
+		pk := gocrypto.PrivKeyFromBytes(ki.PrivateKey)
+		return address.NewSecp256k1Address(pk.PubKey().SerializeUncompressed())
+	case types.KTBLS:
+		var sk ffi.PrivateKey
+		copy(sk[:], ki.PrivateKey)
+		pub := ffi.PrivateKeyPublicKey(sk)
+		return address.NewBLSAddress(pub[:])
+	default:
+		return address.Undef, xerrors.Errorf("unsupported key type %q", ki.Type)
+	}
+}
+
+// signKeyInfo signs msg with a raw private key for the appropriate curve.
+func signKeyInfo(ki *types.KeyInfo, msg []byte) (*crypto.Signature, error) {
+	switch ki.Type {
+	case types.KTSecp256k1:
+		pk := gocrypto.PrivKeyFromBytes(ki.PrivateKey)
+		sig, err := gocrypto.Sign(pk, msg)
+		if err != nil {
+			return nil, xerrors.Errorf("secp256k1 sign: %w", err)
+		}
+		return &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: sig}, nil
+	case types.KTBLS:
+		var sk ffi.PrivateKey
+		copy(sk[:], ki.PrivateKey)
+		sig := ffi.PrivateKeySign(sk, msg)
+		return &crypto.Signature{Type: crypto.SigTypeBLS, Data: sig[:]}, nil
+	default:
+		return nil, xerrors.Errorf("unsupported key type %q", ki.Type)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-state-types/crypto"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/types"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
 	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
@@ -21,6 +22,7 @@ type MultiWallet struct {
 	Local  *LocalWallet               `optional:"true"`
 	Remote *remotewallet.RemoteWallet `optional:"true"`
 	Ledger *ledgerwallet.LedgerWallet `optional:"true"`
+	Seed   *SeedWallet                `optional:"true"`
 }
 
 type getif interface {
@@ -76,7 +78,7 @@ func (m MultiWallet) WalletNew(ctx context.Context, keyType types.KeyType) (addr
 		local = m.Ledger
 	}
 
-	w := firstNonNil(m.Remote, local)
+	w := firstNonNil(m.Remote, local, m.Seed)
 	if w == nil {
 		return address.Undef, xerrors.Errorf("no wallet backends supporting key type: %s", keyType)
 	}
@@ -85,15 +87,28 @@ func (m MultiWallet) WalletNew(ctx context.Context, keyType types.KeyType) (addr
 }
 
 func (m MultiWallet) WalletHas(ctx context.Context, address address.Address) (bool, error) {
-	w, err := m.find(ctx, address, m.Remote, m.Ledger, m.Local)
+	w, err := m.find(ctx, address, m.Remote, m.Ledger, m.Local, m.Seed)
 	return w != nil, err
 }
 
+// WalletSeedRestore initializes or re-scans the seed-derived wallet backend
+// from mnemonic, importing every address with on-chain activity found
+// within gapLimit consecutive unused addresses. force must be set to
+// overwrite a different mnemonic already configured; see
+// SeedWallet.WalletSeedRestore.
+func (m MultiWallet) WalletSeedRestore(ctx context.Context, sm *stmgr.StateManager, mnemonic string, gapLimit uint64, force bool) error {
+	if m.Seed == nil {
+		return xerrors.Errorf("no seed wallet backend configured")
+	}
+
+	return m.Seed.WalletSeedRestore(ctx, sm, mnemonic, gapLimit, force)
+}
+
 func (m MultiWallet) WalletList(ctx context.Context) ([]address.Address, error) {
 	out := make([]address.Address, 0)
 	seen := map[address.Address]struct{}{}
 
-	ws := nonNil(m.Remote, m.Ledger, m.Local)
+	ws := nonNil(m.Remote, m.Ledger, m.Local, m.Seed)
 	for _, w := range ws {
 		l, err := w.WalletList(ctx)
 		if err != nil {
@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestHardenedChildDeterministic(t *testing.T) {
+	key := make([]byte, 32)
+	chainCode := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+		chainCode[i] = byte(i + 1)
+	}
+
+	k1, c1 := hardenedChild(key, chainCode, 44)
+	k2, c2 := hardenedChild(key, chainCode, 44)
+	require.Equal(t, k1, k2)
+	require.Equal(t, c1, c2)
+
+	k3, _ := hardenedChild(key, chainCode, 45)
+	require.NotEqual(t, k1, k3)
+}
+
+func TestNormalChildDeterministic(t *testing.T) {
+	key := make([]byte, 32)
+	chainCode := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i + 1)
+		chainCode[i] = byte(i + 2)
+	}
+
+	k1, c1, err := normalChild(key, chainCode, 0)
+	require.NoError(t, err)
+	k2, c2, err := normalChild(key, chainCode, 0)
+	require.NoError(t, err)
+	require.Equal(t, k1, k2)
+	require.Equal(t, c1, c2)
+
+	k3, _, err := normalChild(key, chainCode, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, k1, k3)
+}
+
+func TestNormalChildRejectsHardenedIndex(t *testing.T) {
+	key := make([]byte, 32)
+	chainCode := make([]byte, 32)
+	_, _, err := normalChild(key, chainCode, 0x80000000)
+	require.Error(t, err)
+}
+
+func TestDeriveKeyInfoSecp256k1Deterministic(t *testing.T) {
+	const mnemonic = "test test test test test test test test test test test junk"
+
+	ki1, err := deriveKeyInfo(mnemonic, types.KTSecp256k1, 0)
+	require.NoError(t, err)
+	ki2, err := deriveKeyInfo(mnemonic, types.KTSecp256k1, 0)
+	require.NoError(t, err)
+	require.Equal(t, ki1.PrivateKey, ki2.PrivateKey)
+
+	ki3, err := deriveKeyInfo(mnemonic, types.KTSecp256k1, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, ki1.PrivateKey, ki3.PrivateKey)
+}
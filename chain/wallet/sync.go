@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// WalletSyncStatus fans a request out to every configured backend that
+// implements api.WalletSyncStatusAPI and merges the result: HeadHeight is
+// the highest head any backend has observed, and per-address statuses are
+// deduplicated by address, keeping whichever backend has scanned furthest
+// for a given address. Backends that don't track sync status of their own
+// (today: Remote, Local and Ledger) are silently skipped rather than erroring.
+func (m MultiWallet) WalletSyncStatus(ctx context.Context) (api.WalletSyncStatus, error) {
+	statuses := make([]api.WalletSyncStatus, 0, 4)
+	for _, w := range syncStatusBackends(nonNil(m.Remote, m.Ledger, m.Local, m.Seed)) {
+		s, err := w.WalletSyncStatus(ctx)
+		if err != nil {
+			return api.WalletSyncStatus{}, err
+		}
+		statuses = append(statuses, s)
+	}
+
+	return mergeSyncStatus(statuses), nil
+}
+
+// syncStatusBackends narrows wallets down to the ones that implement
+// api.WalletSyncStatusAPI.
+func syncStatusBackends(wallets []api.WalletAPI) []api.WalletSyncStatusAPI {
+	out := make([]api.WalletSyncStatusAPI, 0, len(wallets))
+	for _, w := range wallets {
+		if s, ok := w.(api.WalletSyncStatusAPI); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func mergeSyncStatus(statuses []api.WalletSyncStatus) api.WalletSyncStatus {
+	var out api.WalletSyncStatus
+	byAddr := map[string]api.AddressSyncStatus{}
+
+	for _, s := range statuses {
+		if s.HeadHeight > out.HeadHeight {
+			out.HeadHeight = s.HeadHeight
+		}
+
+		for _, a := range s.Addresses {
+			key := a.Address.String()
+			if existing, ok := byAddr[key]; !ok || a.LastScannedEpoch > existing.LastScannedEpoch {
+				byAddr[key] = a
+			}
+		}
+	}
+
+	if len(byAddr) > 0 {
+		out.Addresses = make([]api.AddressSyncStatus, 0, len(byAddr))
+	}
+	for _, a := range byAddr {
+		out.Addresses = append(out.Addresses, a)
+	}
+
+	return out
+}
+
+// WalletSyncedUpdates fans in updates from every configured backend's own
+// WalletSyncedUpdates channel (see syncStatusBackends), re-merging and
+// re-emitting a WalletSyncStatus each time any backend reports a change.
+// The returned channel is closed once ctx is done and every backend's
+// update channel has drained.
+func (m MultiWallet) WalletSyncedUpdates(ctx context.Context) (<-chan api.WalletSyncStatus, error) {
+	backends := syncStatusBackends(nonNil(m.Remote, m.Ledger, m.Local, m.Seed))
+
+	latest := make([]api.WalletSyncStatus, len(backends))
+	var mu sync.Mutex
+
+	out := make(chan api.WalletSyncStatus, 1)
+	var wg sync.WaitGroup
+
+	for i, w := range backends {
+		ch, err := w.WalletSyncedUpdates(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		wg.Add(1)
+		go func(i int, ch <-chan api.WalletSyncStatus) {
+			defer wg.Done()
+			for s := range ch {
+				mu.Lock()
+				latest[i] = s
+				merged := mergeSyncStatus(latest)
+				mu.Unlock()
+
+				select {
+				case out <- merged:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i, ch)
+	}
+
+	go func() {
+		<-ctx.Done()
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func mustAddr(t *testing.T, s string) address.Address {
+	t.Helper()
+	a, err := address.NewFromString(s)
+	require.NoError(t, err)
+	return a
+}
+
+func TestMergeSyncStatusEmpty(t *testing.T) {
+	out := mergeSyncStatus(nil)
+	require.Equal(t, api.WalletSyncStatus{}, out)
+}
+
+func TestMergeSyncStatusHeadHeightIsMax(t *testing.T) {
+	out := mergeSyncStatus([]api.WalletSyncStatus{
+		{HeadHeight: 10},
+		{HeadHeight: 42},
+		{HeadHeight: 7},
+	})
+	require.EqualValues(t, 42, out.HeadHeight)
+}
+
+func TestMergeSyncStatusKeepsFurthestScannedPerAddress(t *testing.T) {
+	addr := mustAddr(t, "f01000")
+
+	out := mergeSyncStatus([]api.WalletSyncStatus{
+		{
+			HeadHeight: 100,
+			Addresses: []api.AddressSyncStatus{
+				{Address: addr, LastScannedEpoch: 50, NoncePending: true},
+			},
+		},
+		{
+			HeadHeight: 100,
+			Addresses: []api.AddressSyncStatus{
+				{Address: addr, LastScannedEpoch: 90, NoncePending: false},
+			},
+		},
+	})
+
+	require.Len(t, out.Addresses, 1)
+	require.EqualValues(t, 90, out.Addresses[0].LastScannedEpoch)
+	require.False(t, out.Addresses[0].NoncePending)
+	// Scanned to 90 against a head of 100: not yet synced.
+	require.False(t, out.Synced())
+}
+
+func TestMergeSyncStatusDeduplicatesByAddress(t *testing.T) {
+	a1 := mustAddr(t, "f01000")
+	a2 := mustAddr(t, "f01001")
+
+	out := mergeSyncStatus([]api.WalletSyncStatus{
+		{HeadHeight: 5, Addresses: []api.AddressSyncStatus{{Address: a1, LastScannedEpoch: 5}}},
+		{HeadHeight: 5, Addresses: []api.AddressSyncStatus{{Address: a2, LastScannedEpoch: 5}}},
+	})
+
+	require.Len(t, out.Addresses, 2)
+}
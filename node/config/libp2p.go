@@ -0,0 +1,24 @@
+package config
+
+// Libp2pUserConfig configures the libp2p host used for chain networking.
+type Libp2pUserConfig struct {
+	ListenAddresses     []string
+	AnnounceAddresses   []string
+	NoAnnounceAddresses []string
+
+	// NATPortMap enables automatic UPnP/NAT-PMP port mapping and
+	// external-address announcement for the libp2p swarm's listen ports.
+	// There's no server/non-server profile distinction in this config yet,
+	// so the zero value leaves it off; DefaultLibp2pUserConfig turns it on,
+	// since most nodes run behind a consumer NAT whose operator would
+	// rather not configure port forwarding manually.
+	NATPortMap bool
+}
+
+// DefaultLibp2pUserConfig returns a Libp2pUserConfig with NATPortMap turned
+// on. The node's default config does not call it.
+func DefaultLibp2pUserConfig() Libp2pUserConfig {
+	return Libp2pUserConfig{
+		NATPortMap: true,
+	}
+}
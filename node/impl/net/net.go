@@ -0,0 +1,35 @@
+package net
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/node/modules"
+)
+
+// NetAPI implements the parts of api.Net this package provides.
+// NatPortMapper is optional: nodes built without node/modules.NATPortMap
+// wired in (or with it disabled) just report an inactive status.
+type NetAPI struct {
+	fx.In
+
+	NatPortMapper *modules.NatPortMapper `optional:"true"`
+}
+
+func (n *NetAPI) NetNATStatus(ctx context.Context) (api.NatInfo, error) {
+	if n.NatPortMapper == nil {
+		return api.NatInfo{}, nil
+	}
+
+	s := n.NatPortMapper.Status()
+	return api.NatInfo{
+		Active:      s.Active,
+		GatewayType: s.GatewayType,
+		ExternalIP:  s.ExternalIP,
+		LeaseExpiry: s.LeaseExpiry,
+	}, nil
+}
+
+var _ api.Net = &NetAPI{}
@@ -2,13 +2,21 @@ package sentinel
 
 import (
 	"context"
+	"sync"
 
 	logging "github.com/ipfs/go-log/v2"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/events"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	cborutil "github.com/filecoin-project/lotus/lib/cborutil"
 	"github.com/filecoin-project/lotus/sentinel"
 )
 
@@ -17,25 +25,421 @@ var log = logging.Logger("sentinel-module")
 type SentinelAPI struct {
 	fx.In
 
-	Events *events.Events
+	Events     *events.Events
+	ChainStore *store.ChainStore
+}
+
+// active tracks the single running IndexingTipSetObserver, if any. Sentinel
+// only supports one active watch per node today; WatchStart called again
+// replaces it.
+var active = &activeWatch{}
+
+type activeWatch struct {
+	mu  sync.Mutex
+	obs *sentinel.IndexingTipSetObserver
+}
+
+// set installs obs as the active watch and returns whatever observer it
+// replaced, if any, so the caller can stop and close it: otherwise its
+// storage handle (Postgres connection, open CSV files) would leak forever,
+// since set is the only place an observer stops being reachable.
+func (a *activeWatch) set(obs *sentinel.IndexingTipSetObserver) *sentinel.IndexingTipSetObserver {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old := a.obs
+	a.obs = obs
+	return old
 }
 
-func (m *SentinelAPI) WatchStart(ctx context.Context) error {
-	log.Info("starting sentinel watch")
-	return m.Events.Observe(&sentinel.LoggingTipSetObserver{})
+func (a *activeWatch) get() *sentinel.IndexingTipSetObserver {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.obs
+}
+
+// stop stops and closes the active watch, if any, and clears it so a
+// subsequent WatchStatus reports no active watch.
+func (a *activeWatch) stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.obs != nil {
+		stopAndClose(a.obs)
+		a.obs = nil
+	}
+}
+
+func stopAndClose(obs *sentinel.IndexingTipSetObserver) {
+	obs.Stop()
+	if err := obs.Close(); err != nil {
+		log.Warnf("closing sentinel storage: %s", err)
+	}
+}
+
+func (m *SentinelAPI) WatchStart(ctx context.Context, tasks []string, storageURI string) error {
+	log.Infow("starting sentinel watch", "tasks", tasks, "storage", storageURI)
+
+	if len(tasks) == 0 {
+		return m.Events.Observe(&sentinel.LoggingTipSetObserver{})
+	}
+
+	st, err := sentinel.NewStorage(storageURI)
+	if err != nil {
+		return xerrors.Errorf("opening sentinel storage: %w", err)
+	}
+
+	obs, err := sentinel.NewIndexingTipSetObserver(st, tasks, m.ChainStore.GetHeaviestTipSet, m.wiredIndexers())
+	if err != nil {
+		return xerrors.Errorf("starting sentinel indexers: %w", err)
+	}
+
+	if old := active.set(obs); old != nil {
+		stopAndClose(old)
+	}
+
+	return m.Events.Observe(obs)
 }
 
 func (m *SentinelAPI) WatchStop(ctx context.Context) error {
 	log.Info("stopping sentinel watch")
+	active.stop()
 	return nil
 }
 
+// WalkChain backfills tasks over [from, to] into storageURI by walking the
+// chain store via parent pointers, without waiting for new tipsets to
+// arrive and without depending on (or being limited to the task set of) any
+// currently-active WatchStart. Because the walk follows parent pointers,
+// from is the newer, higher end of the range and to the older, lower end:
+// from must be >= to, which reads backwards from the "from older to newer"
+// ordering one might expect.
+func (m *SentinelAPI) WalkChain(ctx context.Context, from, to abi.ChainEpoch, tasks []string, storageURI string) error {
+	if from < to {
+		return xerrors.Errorf("from (%d) must be >= to (%d): WalkChain walks newest-to-oldest, so from is the newer end of the range", from, to)
+	}
+	if len(tasks) == 0 {
+		return xerrors.Errorf("no tasks given to backfill")
+	}
+
+	st, err := sentinel.NewStorage(storageURI)
+	if err != nil {
+		return xerrors.Errorf("opening sentinel storage: %w", err)
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			log.Warnf("closing sentinel backfill storage: %s", err)
+		}
+	}()
+
+	obs, err := sentinel.NewIndexingTipSetObserver(st, tasks, m.ChainStore.GetHeaviestTipSet, m.wiredIndexers())
+	if err != nil {
+		return xerrors.Errorf("starting sentinel backfill indexers: %w", err)
+	}
+
+	ts, err := m.ChainStore.GetTipsetByHeight(ctx, from, m.ChainStore.GetHeaviestTipSet(), true)
+	if err != nil {
+		return xerrors.Errorf("loading backfill start tipset: %w", err)
+	}
+
+	for ts != nil && ts.Height() >= to {
+		if err := obs.Apply(ctx, ts, ts); err != nil {
+			return xerrors.Errorf("indexing tipset %d during backfill: %w", ts.Height(), err)
+		}
+
+		ts, err = m.ChainStore.LoadTipSet(ts.Parents())
+		if err != nil {
+			return xerrors.Errorf("loading parent tipset during backfill: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// wiredIndexers builds the task-name -> Indexer overrides for tasks that
+// need chain data beyond the tipset itself: MessageIndexer and
+// ReceiptIndexer can't resolve their GetBlockMessages/GetReceipts closures
+// on their own since sentinel.Tasks only holds zero-value, dependency-free
+// instances. Passed to sentinel.NewIndexingTipSetObserver by both
+// WatchStart and WalkChain.
+func (m *SentinelAPI) wiredIndexers() map[string]sentinel.Indexer {
+	return map[string]sentinel.Indexer{
+		sentinel.TaskMessage: &sentinel.MessageIndexer{GetBlockMessages: m.getBlockMessages},
+		sentinel.TaskReceipt: &sentinel.ReceiptIndexer{GetReceipts: m.getReceipts},
+	}
+}
+
+func (m *SentinelAPI) getBlockMessages(ctx context.Context, ts *types.TipSet) ([]sentinel.MessageModel, error) {
+	msgs, err := m.messagesForTipSet(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]sentinel.MessageModel, 0, len(msgs))
+	for _, msg := range msgs {
+		out = append(out, sentinel.MessageModel{
+			Cid:    msg.Cid().String(),
+			From:   msg.From.String(),
+			To:     msg.To.String(),
+			Nonce:  msg.Nonce,
+			Method: uint64(msg.Method),
+		})
+	}
+	return out, nil
+}
+
+// getReceipts pairs ts's parent message receipts with the CIDs of the
+// messages that produced them, i.e. the messages of ts's parent tipset; both
+// are ordered identically by the chain store.
+func (m *SentinelAPI) getReceipts(ctx context.Context, ts *types.TipSet) ([]sentinel.ReceiptModel, error) {
+	pts, err := m.ChainStore.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := m.messagesForTipSet(ctx, pts)
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err := m.ChainStore.GetParentReceipts(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]sentinel.ReceiptModel, 0, len(recs))
+	for i, r := range recs {
+		var msgCid string
+		if i < len(msgs) {
+			msgCid = msgs[i].Cid().String()
+		}
+		out = append(out, sentinel.ReceiptModel{
+			MessageCid: msgCid,
+			ExitCode:   int64(r.ExitCode),
+			GasUsed:    r.GasUsed,
+		})
+	}
+	return out, nil
+}
+
+func (m *SentinelAPI) WatchStatus(ctx context.Context) (api.SentinelWatchStatus, error) {
+	out := api.SentinelWatchStatus{}
+
+	if obs := active.get(); obs != nil {
+		for _, s := range obs.Status() {
+			out.Tasks = append(out.Tasks, api.SentinelTaskStatus{
+				Task:       s.Task,
+				LastHeight: abi.ChainEpoch(s.LastHeight),
+				Running:    s.Running,
+				Lag:        abi.ChainEpoch(s.Lag),
+				Error:      s.Error,
+			})
+		}
+	}
+
+	var tipsets, messages, states uint64
+	if h := peekHub(); h != nil {
+		tipsets, messages, states = h.DroppedCounts()
+	}
+	out.Streams = []api.SentinelStreamStatus{
+		{Stream: "tipset", Dropped: tipsets},
+		{Stream: "message", Dropped: messages},
+		{Stream: "state", Dropped: states},
+	}
+
+	return out, nil
+}
+
+// hub lazily starts the shared streaming Hub and registers it as a
+// TipSetObserver the first time any stream is requested. If registration
+// fails, the attempt is not cached: the next call tries again instead of
+// handing out a Hub that will never receive any event.
+func (m *SentinelAPI) hub() (*sentinel.Hub, error) {
+	sharedHub.mu.Lock()
+	defer sharedHub.mu.Unlock()
+
+	if sharedHub.hub != nil {
+		return sharedHub.hub, nil
+	}
+
+	h := sentinel.NewHub(m.messagesForTipSet, m.actorStateRoots)
+	if err := m.Events.Observe(h); err != nil {
+		return nil, xerrors.Errorf("registering sentinel stream hub: %w", err)
+	}
+
+	sharedHub.hub = h
+	return h, nil
+}
+
+// peekHub returns the shared streaming Hub without starting it, or nil if
+// no stream has been requested yet.
+func peekHub() *sentinel.Hub {
+	sharedHub.mu.Lock()
+	defer sharedHub.mu.Unlock()
+	return sharedHub.hub
+}
+
+func (m *SentinelAPI) messagesForTipSet(ctx context.Context, ts *types.TipSet) ([]*types.Message, error) {
+	return m.ChainStore.MessagesForTipset(ts)
+}
+
+func (m *SentinelAPI) actorStateRoots(ctx context.Context, ts *types.TipSet, actors []address.Address) (map[address.Address]cid.Cid, error) {
+	out := make(map[address.Address]cid.Cid, len(actors))
+	for _, a := range actors {
+		act, err := m.ChainStore.GetActor(a, ts)
+		if err != nil {
+			continue
+		}
+		out[a] = act.Head
+	}
+	return out, nil
+}
+
+// TipSetStream streams tipsets at the confidence depth the streaming hub
+// was started with; opts.Confidence is accepted for forward compatibility
+// but all subscribers currently share one hub and one depth.
+func (m *SentinelAPI) TipSetStream(ctx context.Context, opts api.SentinelStreamOpts) (<-chan api.SentinelTipSetEvent, error) {
+	h, err := m.hub()
+	if err != nil {
+		return nil, err
+	}
+
+	in, cancel, err := h.TipSetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan api.SentinelTipSetEvent, sentinel.StreamBufferLen)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					return
+				}
+				payload, err := cborutil.Dump(ev.TipSet.Blocks())
+				if err != nil {
+					log.Warnf("encoding tipset stream payload: %s", err)
+					continue
+				}
+				select {
+				case out <- api.SentinelTipSetEvent{
+					TipSetKey: ev.TipSet.Key(),
+					Height:    ev.TipSet.Height(),
+					Reverted:  ev.Reverted,
+					Payload:   payload,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (m *SentinelAPI) MessageStream(ctx context.Context, filter api.SentinelMessageFilter) (<-chan api.SentinelMessageEvent, error) {
+	h, err := m.hub()
+	if err != nil {
+		return nil, err
+	}
+
+	in, cancel, err := h.MessageStream(sentinel.MessageFilter{From: filter.From, To: filter.To})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan api.SentinelMessageEvent, sentinel.StreamBufferLen)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					return
+				}
+				payload, err := cborutil.Dump(ev.Message)
+				if err != nil {
+					log.Warnf("encoding message stream payload: %s", err)
+					continue
+				}
+				select {
+				case out <- api.SentinelMessageEvent{
+					TipSetKey: ev.TipSet.Key(),
+					Height:    ev.TipSet.Height(),
+					Reverted:  ev.Reverted,
+					Payload:   payload,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (m *SentinelAPI) StateChangeStream(ctx context.Context, actors []address.Address) (<-chan api.SentinelStateDelta, error) {
+	h, err := m.hub()
+	if err != nil {
+		return nil, err
+	}
+
+	in, cancel, err := h.StateChangeStream(actors)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan api.SentinelStateDelta, sentinel.StreamBufferLen)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- api.SentinelStateDelta{
+					TipSetKey: ev.TipSet.Key(),
+					Height:    ev.TipSet.Height(),
+					Actor:     ev.Actor,
+					StateRoot: ev.StateRoot,
+					Reverted:  ev.Reverted,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+var sharedHub = &hubHolder{}
+
+type hubHolder struct {
+	mu  sync.Mutex
+	hub *sentinel.Hub
+}
+
 // SentinelUnavailable is an implementation of the sentinel api that returns an unavailable error for every request
 type SentinelUnavailable struct {
 	fx.In
 }
 
-func (SentinelUnavailable) WatchStart(ctx context.Context) error {
+func (SentinelUnavailable) WatchStart(ctx context.Context, tasks []string, storage string) error {
 	return xerrors.Errorf("sentinel unavailable")
 }
 
@@ -43,4 +447,24 @@ func (SentinelUnavailable) WatchStop(ctx context.Context) error {
 	return xerrors.Errorf("sentinel unavailable")
 }
 
+func (SentinelUnavailable) WalkChain(ctx context.Context, from, to abi.ChainEpoch, tasks []string, storage string) error {
+	return xerrors.Errorf("sentinel unavailable")
+}
+
+func (SentinelUnavailable) WatchStatus(ctx context.Context) (api.SentinelWatchStatus, error) {
+	return api.SentinelWatchStatus{}, xerrors.Errorf("sentinel unavailable")
+}
+
+func (SentinelUnavailable) TipSetStream(ctx context.Context, opts api.SentinelStreamOpts) (<-chan api.SentinelTipSetEvent, error) {
+	return nil, xerrors.Errorf("sentinel unavailable")
+}
+
+func (SentinelUnavailable) MessageStream(ctx context.Context, filter api.SentinelMessageFilter) (<-chan api.SentinelMessageEvent, error) {
+	return nil, xerrors.Errorf("sentinel unavailable")
+}
+
+func (SentinelUnavailable) StateChangeStream(ctx context.Context, actors []address.Address) (<-chan api.SentinelStateDelta, error) {
+	return nil, xerrors.Errorf("sentinel unavailable")
+}
+
 var _ api.Sentinel = &SentinelAPI{}
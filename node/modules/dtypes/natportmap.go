@@ -0,0 +1,17 @@
+package dtypes
+
+import "time"
+
+// NatPortMapEnabled is an fx-injected bool controlling whether
+// node/modules.NATPortMap actually runs its discovery/mapping loop. It's
+// derived from the user's Libp2p.NATPortMap config toggle.
+type NatPortMapEnabled bool
+
+// NatStatus reports the current state of the node's UPnP/NAT-PMP port
+// mapping, as last observed by node/modules.NatPortMapper.
+type NatStatus struct {
+	Active      bool
+	GatewayType string
+	ExternalIP  string
+	LeaseExpiry time.Time
+}
@@ -0,0 +1,231 @@
+package modules
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	nat "github.com/libp2p/go-nat"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"go.uber.org/fx"
+
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+)
+
+// natLeaseDuration is how long each UPnP/NAT-PMP port mapping is requested
+// for; natRefreshInterval is how often it's renewed, 5 minutes before the
+// lease runs out. A single failed refresh lets the mapping lapse until the
+// next one succeeds.
+const (
+	natLeaseDuration   = 20 * time.Minute
+	natRefreshInterval = 15 * time.Minute
+)
+
+// NatPortMapper discovers a UPnP/NAT-PMP gateway on the LAN, maps the
+// node's libp2p swarm ports through it, and publishes the resulting
+// external multiaddr so peers can dial in without operator port forwarding.
+type NatPortMapper struct {
+	mu          sync.Mutex
+	status      dtypes.NatStatus
+	gw          nat.NAT
+	mappedPorts []int
+}
+
+// Status returns the last known state of the port mapping.
+func (n *NatPortMapper) Status() dtypes.NatStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.status
+}
+
+func (n *NatPortMapper) setStatus(s dtypes.NatStatus) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status = s
+}
+
+// release removes every port mapping currently held on the gateway. Called
+// on OnStop so a clean shutdown doesn't leave the mapping open on the
+// gateway for the rest of natLeaseDuration after the node has stopped
+// listening on it.
+func (n *NatPortMapper) release() {
+	n.mu.Lock()
+	gw, ports := n.gw, n.mappedPorts
+	n.gw, n.mappedPorts = nil, nil
+	n.mu.Unlock()
+
+	if gw == nil {
+		return
+	}
+
+	for _, port := range ports {
+		if err := gw.DeletePortMapping("tcp", port); err != nil {
+			log.Warnf("natportmap: releasing port %d failed: %s", port, err)
+		}
+	}
+}
+
+// NatPortMapEnabled adapts the user's Libp2p.NATPortMap config toggle into
+// the dtypes.NatPortMapEnabled value NATPortMap takes as an fx dependency.
+// Neither it nor NATPortMap is registered with the node builder's fx options.
+func NatPortMapEnabled(cfg config.Libp2pUserConfig) dtypes.NatPortMapEnabled {
+	return dtypes.NatPortMapEnabled(cfg.NATPortMap)
+}
+
+// NATPortMap discovers a NAT gateway and maps the host's listen ports
+// through it, refreshing the mapping until the lifecycle is stopped. If
+// enabled is false it returns a NatPortMapper that always reports an
+// inactive status and does nothing else. config.DefaultLibp2pUserConfig
+// turns the toggle on.
+func NATPortMap(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, enabled dtypes.NatPortMapEnabled) (*NatPortMapper, error) {
+	mapper := &NatPortMapper{}
+	if !enabled {
+		return mapper, nil
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	stop := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go mapper.run(ctx, h, stop)
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			close(stop)
+			mapper.release()
+			mapper.setStatus(dtypes.NatStatus{})
+			return nil
+		},
+	})
+
+	return mapper, nil
+}
+
+func (n *NatPortMapper) run(ctx context.Context, h host.Host, stop chan struct{}) {
+	gw, err := nat.DiscoverGateway(ctx)
+	if err != nil {
+		log.Warnf("natportmap: no UPnP/NAT-PMP gateway found: %s", err)
+		return
+	}
+
+	n.mapAndAnnounce(gw, h)
+
+	t := time.NewTicker(natRefreshInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			n.mapAndAnnounce(gw, h)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// mapAndAnnounce requests a mapping for every TCP listen port of h, then
+// installs an AddrsFactory that advertises the mapped external addresses
+// alongside the host's local ones.
+func (n *NatPortMapper) mapAndAnnounce(gw nat.NAT, h host.Host) {
+	extIP, err := gw.GetExternalAddress()
+	if err != nil {
+		log.Warnf("natportmap: getting external address: %s", err)
+		return
+	}
+
+	var mapped []ma.Multiaddr
+	var ports []int
+	for _, addr := range tcpListenAddrs(h.Addrs()) {
+		extPort, err := gw.AddPortMapping("tcp", addr.port, "lotus", natLeaseDuration)
+		if err != nil {
+			log.Warnf("natportmap: mapping port %d failed: %s", addr.port, err)
+			continue
+		}
+
+		extMaddr, err := ma.NewMultiaddr("/ip4/" + extIP.String() + "/tcp/" + strconv.Itoa(extPort))
+		if err != nil {
+			continue
+		}
+		mapped = append(mapped, extMaddr)
+		ports = append(ports, addr.port)
+	}
+
+	if len(mapped) == 0 {
+		return
+	}
+
+	// Remembered so OnStop can release exactly the ports this mapper is
+	// holding open, rather than leaving them mapped until natLeaseDuration
+	// expires on the gateway side.
+	n.mu.Lock()
+	n.gw = gw
+	n.mappedPorts = ports
+	n.mu.Unlock()
+
+	// SetAddrsFactory lives on the concrete host implementation (e.g.
+	// basichost.BasicHost), not on the swarm returned by h.Network(), and
+	// isn't guaranteed to exist on every host.Host implementation. Guard
+	// with comma-ok instead of asserting: a host that doesn't support it
+	// should fall back to reporting the mapping as inactive rather than
+	// panicking the node the first time a gateway is found.
+	setter, ok := h.(addrsFactorySetter)
+	if !ok {
+		log.Warnf("natportmap: host %T does not support SetAddrsFactory, mapped address(es) %v will not be announced", h, mapped)
+		return
+	}
+
+	setter.SetAddrsFactory(func(addrs []ma.Multiaddr) []ma.Multiaddr {
+		return append(addrs, mapped...)
+	})
+
+	n.setStatus(dtypes.NatStatus{
+		Active:      true,
+		GatewayType: gw.Type(),
+		ExternalIP:  extIP.String(),
+		LeaseExpiry: time.Now().Add(natLeaseDuration),
+	})
+}
+
+// addrsFactorySetter is satisfied by host.Host implementations (basichost.BasicHost
+// in practice) that allow replacing the function used to compute a host's
+// advertised addresses after construction.
+type addrsFactorySetter interface {
+	SetAddrsFactory(func([]ma.Multiaddr) []ma.Multiaddr)
+}
+
+type tcpAddr struct {
+	port int
+}
+
+// tcpListenAddrs picks out the TCP ports among a host's listen multiaddrs;
+// QUIC and other transports aren't mappable through UPnP/NAT-PMP the same
+// way and are left for peers to reach via other NAT traversal mechanisms.
+func tcpListenAddrs(addrs []ma.Multiaddr) []tcpAddr {
+	var out []tcpAddr
+	for _, addr := range addrs {
+		netw, host, err := manet.DialArgs(addr)
+		if err != nil || netw != "tcp" {
+			continue
+		}
+
+		_, portStr, err := net.SplitHostPort(host)
+		if err != nil {
+			continue
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+
+		out = append(out, tcpAddr{port: port})
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package sentinel
+
+import (
+	"context"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// Indexer extracts one kind of Model from a TipSet. Indexers are registered
+// under a task name (see Tasks) and run by an IndexingTipSetObserver each
+// time a new, confident tipset is observed.
+type Indexer interface {
+	// Process extracts zero or more Models from ts. It must not mutate ts.
+	Process(ctx context.Context, ts *types.TipSet) ([]Model, error)
+}
+
+// Task names accepted by WatchStart/WalkChain and reported in WatchStatus.
+const (
+	TaskTipSet           = "tipset"
+	TaskBlockHeader      = "block_header"
+	TaskMessage          = "message"
+	TaskReceipt          = "receipt"
+	TaskActorStateDelta  = "actor_state_delta"
+	TaskMinerSectorEvent = "miner_sector_event"
+)
+
+// Tasks is the registry of built-in indexers, keyed by task name. Only
+// tasks wired to a real data source by their caller belong here: an
+// Indexer whose Process always returns (nil, nil) would report Running:
+// true in WatchStatus while silently indexing nothing, which is worse than
+// rejecting the task name outright.
+//
+// TaskActorStateDelta and TaskMinerSectorEvent are declared above as
+// reserved names but are deliberately left out of this registry: indexing
+// them needs a full actor-state-tree diff between a tipset and its parent,
+// which nothing in this package or node/impl/sentinel currently provides.
+// Add them back here once something wires ActorStateDeltaIndexer.GetDeltas
+// and MinerSectorEventIndexer.GetEvents to a real implementation.
+var Tasks = map[string]Indexer{
+	TaskTipSet:      &TipSetIndexer{},
+	TaskBlockHeader: &BlockHeaderIndexer{},
+	TaskMessage:     &MessageIndexer{},
+	TaskReceipt:     &ReceiptIndexer{},
+}
+
+// TipSetIndexer records one TipSetModel per observed tipset.
+type TipSetIndexer struct{}
+
+func (*TipSetIndexer) Process(_ context.Context, ts *types.TipSet) ([]Model, error) {
+	return []Model{
+		TipSetModel{
+			Height:    int64(ts.Height()),
+			TipSetKey: ts.Key().String(),
+			ParentKey: ts.Parents().String(),
+		},
+	}, nil
+}
+
+// BlockHeaderIndexer records one BlockHeaderModel per block in the tipset.
+type BlockHeaderIndexer struct{}
+
+func (*BlockHeaderIndexer) Process(_ context.Context, ts *types.TipSet) ([]Model, error) {
+	out := make([]Model, 0, len(ts.Blocks()))
+	for _, b := range ts.Blocks() {
+		out = append(out, BlockHeaderModel{
+			Cid:       b.Cid().String(),
+			Height:    int64(b.Height),
+			Miner:     b.Miner.String(),
+			Timestamp: b.Timestamp,
+		})
+	}
+	return out, nil
+}
+
+// MessageIndexer records one MessageModel per message included in the
+// tipset's blocks. GetBlockMessages needs a chain store handle, so it's left
+// unset here and wired up by node/impl/sentinel when the task is selected;
+// without it Process is a no-op rather than an error, which is why this
+// indexer must only be reachable through a caller that does the wiring (see
+// the comment on Tasks).
+type MessageIndexer struct {
+	GetBlockMessages func(ctx context.Context, ts *types.TipSet) ([]MessageModel, error)
+}
+
+func (m *MessageIndexer) Process(ctx context.Context, ts *types.TipSet) ([]Model, error) {
+	if m.GetBlockMessages == nil {
+		return nil, nil
+	}
+	msgs, err := m.GetBlockMessages(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Model, 0, len(msgs))
+	for _, msg := range msgs {
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// ReceiptIndexer records one ReceiptModel per message receipt produced while
+// executing the tipset's parent state transition. GetReceipts is wired up by
+// node/impl/sentinel the same way as MessageIndexer.GetBlockMessages.
+type ReceiptIndexer struct {
+	GetReceipts func(ctx context.Context, ts *types.TipSet) ([]ReceiptModel, error)
+}
+
+func (r *ReceiptIndexer) Process(ctx context.Context, ts *types.TipSet) ([]Model, error) {
+	if r.GetReceipts == nil {
+		return nil, nil
+	}
+	recs, err := r.GetReceipts(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Model, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// ActorStateDeltaIndexer records one ActorStateDeltaModel per actor whose
+// state root changed between ts and its parent. Not registered in Tasks:
+// nothing wires GetDeltas to a real actor-state-tree diff yet (see the
+// comment on Tasks).
+type ActorStateDeltaIndexer struct {
+	GetDeltas func(ctx context.Context, ts *types.TipSet) ([]ActorStateDeltaModel, error)
+}
+
+func (a *ActorStateDeltaIndexer) Process(ctx context.Context, ts *types.TipSet) ([]Model, error) {
+	if a.GetDeltas == nil {
+		return nil, nil
+	}
+	deltas, err := a.GetDeltas(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Model, 0, len(deltas))
+	for _, d := range deltas {
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// MinerSectorEventIndexer records one MinerSectorEventModel per sector
+// lifecycle event emitted by miner actors during the tipset's state
+// transition. Not registered in Tasks for the same reason as
+// ActorStateDeltaIndexer.
+type MinerSectorEventIndexer struct {
+	GetEvents func(ctx context.Context, ts *types.TipSet) ([]MinerSectorEventModel, error)
+}
+
+func (s *MinerSectorEventIndexer) Process(ctx context.Context, ts *types.TipSet) ([]Model, error) {
+	if s.GetEvents == nil {
+		return nil, nil
+	}
+	evts, err := s.GetEvents(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Model, 0, len(evts))
+	for _, e := range evts {
+		out = append(out, e)
+	}
+	return out, nil
+}
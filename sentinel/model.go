@@ -0,0 +1,69 @@
+package sentinel
+
+// Model is a single row/record produced by an Indexer. Concrete indexers
+// return slices of whatever shape matches the storage table they populate;
+// Storage implementations type-switch on Model to decide how to persist it.
+type Model interface {
+	// Table returns the storage table (SQL) or file (CSV) this model belongs to.
+	Table() string
+}
+
+// TipSetModel indexes a single tipset.
+type TipSetModel struct {
+	Height    int64
+	TipSetKey string
+	ParentKey string
+}
+
+func (TipSetModel) Table() string { return "tipsets" }
+
+// BlockHeaderModel indexes a single block header within a tipset.
+type BlockHeaderModel struct {
+	Cid       string
+	Height    int64
+	Miner     string
+	Timestamp uint64
+}
+
+func (BlockHeaderModel) Table() string { return "block_headers" }
+
+// MessageModel indexes an on-chain message.
+type MessageModel struct {
+	Cid    string
+	From   string
+	To     string
+	Nonce  uint64
+	Method uint64
+}
+
+func (MessageModel) Table() string { return "messages" }
+
+// ReceiptModel indexes a message receipt.
+type ReceiptModel struct {
+	MessageCid string
+	ExitCode   int64
+	GasUsed    int64
+}
+
+func (ReceiptModel) Table() string { return "receipts" }
+
+// ActorStateDeltaModel indexes a change to an actor's on-chain state between
+// two tipsets.
+type ActorStateDeltaModel struct {
+	Height    int64
+	Actor     string
+	StateRoot string
+}
+
+func (ActorStateDeltaModel) Table() string { return "actor_state_deltas" }
+
+// MinerSectorEventModel indexes a sector lifecycle event (precommit, commit,
+// fault, terminate, ...) emitted by a miner actor.
+type MinerSectorEventModel struct {
+	Height   int64
+	Miner    string
+	SectorID uint64
+	Event    string
+}
+
+func (MinerSectorEventModel) Table() string { return "miner_sector_events" }
@@ -0,0 +1,195 @@
+package sentinel
+
+import (
+	"context"
+	"sync"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var log = logging.Logger("sentinel")
+
+// LoggingTipSetObserver is the original, minimal observer: it just logs the
+// tipsets it sees. Kept around for WatchStart callers that don't pass any
+// tasks.
+type LoggingTipSetObserver struct{}
+
+func (*LoggingTipSetObserver) Apply(_ context.Context, _, ts *types.TipSet) error {
+	log.Infow("sentinel observed tipset", "height", ts.Height(), "key", ts.Key())
+	return nil
+}
+
+func (*LoggingTipSetObserver) Revert(_ context.Context, _, ts *types.TipSet) error {
+	log.Infow("sentinel reverted tipset", "height", ts.Height(), "key", ts.Key())
+	return nil
+}
+
+// TaskStatus reports the progress of a single running task.
+type TaskStatus struct {
+	Task       string
+	LastHeight int64
+	Running    bool
+	Lag        int64
+	Error      string
+}
+
+// IndexingTipSetObserver runs a set of Indexers against every confident
+// tipset it's given and persists the resulting Models to Storage. It
+// satisfies the TipSetObserver interface expected by events.Events.Observe.
+type IndexingTipSetObserver struct {
+	storage Storage
+	headFn  func() *types.TipSet
+
+	mu    sync.Mutex
+	tasks map[string]Indexer
+	stats map[string]*TaskStatus
+}
+
+// NewIndexingTipSetObserver builds an observer running the given task names
+// (must be keys of Tasks) against storage. headFn is used to compute lag in
+// Status() and may be nil. wired overrides entries of Tasks by name with
+// Indexer instances the caller has already bound to a live data source (see
+// node/impl/sentinel, which wires MessageIndexer and ReceiptIndexer to the
+// chain store this way); it may be nil.
+func NewIndexingTipSetObserver(storage Storage, tasks []string, headFn func() *types.TipSet, wired map[string]Indexer) (*IndexingTipSetObserver, error) {
+	selected := make(map[string]Indexer, len(tasks))
+	stats := make(map[string]*TaskStatus, len(tasks))
+	for _, t := range tasks {
+		idx, ok := Tasks[t]
+		if !ok {
+			return nil, xerrors.Errorf("unknown sentinel task %q", t)
+		}
+		if w, ok := wired[t]; ok {
+			idx = w
+		}
+		selected[t] = idx
+		stats[t] = &TaskStatus{Task: t, Running: true}
+	}
+
+	return &IndexingTipSetObserver{
+		storage: storage,
+		headFn:  headFn,
+		tasks:   selected,
+		stats:   stats,
+	}, nil
+}
+
+func (o *IndexingTipSetObserver) Apply(ctx context.Context, _, ts *types.TipSet) error {
+	o.mu.Lock()
+	if !o.runningLocked() {
+		o.mu.Unlock()
+		return nil
+	}
+	tasks := make(map[string]Indexer, len(o.tasks))
+	for k, v := range o.tasks {
+		tasks[k] = v
+	}
+	o.mu.Unlock()
+
+	// Run every task and collect its models before persisting anything, so
+	// one task's Process error can't leave another task's already-produced
+	// models unpersisted with its progress already advanced (map iteration
+	// order is randomized, so that risk isn't limited to "later" tasks in
+	// source order).
+	var batch []Model
+	produced := make(map[string]bool, len(tasks))
+	for name, idx := range tasks {
+		models, err := idx.Process(ctx, ts)
+		if err != nil {
+			o.setErr(name, err)
+			return err
+		}
+		batch = append(batch, models...)
+		produced[name] = true
+	}
+
+	if len(batch) > 0 {
+		if err := o.storage.PersistModels(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	// Only now, with the batch durably persisted, advance progress for the
+	// tasks it came from.
+	for name := range produced {
+		o.setProgress(name, int64(ts.Height()))
+	}
+
+	return nil
+}
+
+func (o *IndexingTipSetObserver) Revert(_ context.Context, _, _ *types.TipSet) error {
+	// Rows already persisted for a reverted tipset are left in storage: SQL
+	// inserts use "on conflict do nothing" and CSV rows are appended, so a
+	// later Apply at the same height adds rows rather than overwriting them.
+	// Already a no-op, so there's nothing for Stop to gate here, unlike Apply.
+	return nil
+}
+
+// runningLocked reports whether any task is still marked running. o.mu must
+// be held. Stop flips every task's Running to false at once, so this is
+// equivalent to "has Stop been called".
+func (o *IndexingTipSetObserver) runningLocked() bool {
+	for _, s := range o.stats {
+		if s.Running {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *IndexingTipSetObserver) setProgress(task string, height int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s := o.stats[task]
+	s.LastHeight = height
+	s.Error = ""
+
+	if o.headFn != nil {
+		if head := o.headFn(); head != nil {
+			s.Lag = int64(head.Height()) - height
+		}
+	}
+}
+
+func (o *IndexingTipSetObserver) setErr(task string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stats[task].Error = err.Error()
+}
+
+// Status returns a snapshot of per-task progress.
+func (o *IndexingTipSetObserver) Status() []TaskStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	out := make([]TaskStatus, 0, len(o.stats))
+	for _, s := range o.stats {
+		out = append(out, *s)
+	}
+	return out
+}
+
+// Stop marks every task as no longer running; Status keeps reporting their
+// last known progress. A stopped observer's Apply becomes a no-op, so even
+// if it's still registered with events.Events (there is no unsubscribe path
+// today) it will not index or persist anything further. Callers that are
+// done with the observer for good should also call Close to release its
+// storage handle.
+func (o *IndexingTipSetObserver) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, s := range o.stats {
+		s.Running = false
+	}
+}
+
+// Close releases the observer's underlying storage handle (Postgres
+// connection, open CSV files, ...). Call it once the observer is no longer
+// going to be Applied to, typically right after Stop.
+func (o *IndexingTipSetObserver) Close() error {
+	return o.storage.Close()
+}
@@ -0,0 +1,30 @@
+package sentinel
+
+import (
+	"reflect"
+	"strings"
+)
+
+// structFields returns the exported field names (lower-cased) and values of
+// m in declaration order. It lets Storage implementations stay generic over
+// the concrete Model types defined in model.go without a hand-written
+// column list per type.
+func structFields(m Model) ([]string, []interface{}) {
+	v := reflect.ValueOf(m)
+	t := v.Type()
+
+	cols := make([]string, 0, t.NumField())
+	vals := make([]interface{}, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		cols = append(cols, strings.ToLower(f.Name))
+		vals = append(vals, v.Field(i).Interface())
+	}
+
+	return cols, vals
+}
@@ -0,0 +1,171 @@
+package sentinel
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	// Postgres driver, registered under "postgres".
+	_ "github.com/lib/pq"
+	"golang.org/x/xerrors"
+)
+
+// Storage persists a batch of Models produced by one or more Indexers.
+// Implementations are expected to be safe for concurrent use.
+type Storage interface {
+	PersistModels(ctx context.Context, models []Model) error
+	Close() error
+}
+
+// NewStorage builds a Storage from a connection string. A "csv://<dir>"
+// scheme writes one file per table under <dir>; anything else is treated as
+// a Postgres DSN.
+func NewStorage(storage string) (Storage, error) {
+	if dir := strings.TrimPrefix(storage, "csv://"); dir != storage {
+		return NewCSVStorage(dir)
+	}
+
+	return NewSQLStorage(storage)
+}
+
+// SQLStorage persists models as rows in a Postgres database, one table per
+// Model.Table().
+type SQLStorage struct {
+	db *sql.DB
+}
+
+func NewSQLStorage(dsn string) (*SQLStorage, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, xerrors.Errorf("opening sentinel postgres storage: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, xerrors.Errorf("connecting to sentinel postgres storage: %w", err)
+	}
+	return &SQLStorage{db: db}, nil
+}
+
+func (s *SQLStorage) PersistModels(ctx context.Context, models []Model) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return xerrors.Errorf("beginning sentinel persist tx: %w", err)
+	}
+
+	for _, m := range models {
+		cols, vals := modelColumns(m)
+		placeholders := make([]string, len(vals))
+		for i := range vals {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+
+		q := fmt.Sprintf("insert into %s (%s) values (%s) on conflict do nothing",
+			m.Table(), strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+
+		if _, err := tx.ExecContext(ctx, q, vals...); err != nil {
+			_ = tx.Rollback()
+			return xerrors.Errorf("persisting %s row: %w", m.Table(), err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStorage) Close() error {
+	return s.db.Close()
+}
+
+// CSVStorage persists models by appending rows to one CSV file per table
+// under a base directory. It is meant for local backfills and debugging,
+// not for serving a production analytics database.
+type CSVStorage struct {
+	dir string
+
+	mu      sync.Mutex
+	writers map[string]*csv.Writer
+	files   map[string]*os.File
+}
+
+func NewCSVStorage(dir string) (*CSVStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, xerrors.Errorf("creating sentinel csv dir: %w", err)
+	}
+
+	return &CSVStorage{
+		dir:     dir,
+		writers: map[string]*csv.Writer{},
+		files:   map[string]*os.File{},
+	}, nil
+}
+
+func (c *CSVStorage) PersistModels(_ context.Context, models []Model) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range models {
+		w, err := c.writerFor(m.Table())
+		if err != nil {
+			return err
+		}
+
+		_, vals := modelColumns(m)
+		row := make([]string, len(vals))
+		for i, v := range vals {
+			row[i] = fmt.Sprintf("%v", v)
+		}
+
+		if err := w.Write(row); err != nil {
+			return xerrors.Errorf("writing %s csv row: %w", m.Table(), err)
+		}
+	}
+
+	for _, w := range c.writers {
+		w.Flush()
+	}
+
+	return nil
+}
+
+func (c *CSVStorage) writerFor(table string) (*csv.Writer, error) {
+	if w, ok := c.writers[table]; ok {
+		return w, nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(c.dir, table+".csv"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, xerrors.Errorf("opening %s csv file: %w", table, err)
+	}
+
+	w := csv.NewWriter(f)
+	c.files[table] = f
+	c.writers[table] = w
+	return w, nil
+}
+
+func (c *CSVStorage) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, w := range c.writers {
+		w.Flush()
+	}
+	for _, f := range c.files {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modelColumns reflects a Model's exported fields into ordered (column,
+// value) pairs using their lower-cased field names as column names.
+func modelColumns(m Model) ([]string, []interface{}) {
+	// Models are small, flat structs; field order is stable via reflection
+	// only at the call sites that need it, so callers that care about exact
+	// column names should keep Model structs in sync with their schema.
+	return structFields(m)
+}
@@ -0,0 +1,337 @@
+package sentinel
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// StreamBufferLen is the channel depth given to every stream subscriber.
+// Once full, the oldest unread event is dropped in favor of the newest
+// rather than blocking indexing on a slow consumer.
+const StreamBufferLen = 32
+
+// TipSetEvent is emitted by Hub.TipSetStream on every applied/reverted tipset.
+type TipSetEvent struct {
+	TipSet   *types.TipSet
+	Reverted bool
+}
+
+// MessageEvent is emitted by Hub.MessageStream for every message carried by
+// an applied/reverted tipset that passes the subscriber's MessageFilter.
+type MessageEvent struct {
+	TipSet   *types.TipSet
+	Message  *types.Message
+	Reverted bool
+}
+
+// StateDelta is emitted by Hub.StateChangeStream for every actor, among the
+// ones a subscriber asked about, whose state root changed across an
+// applied/reverted tipset.
+type StateDelta struct {
+	TipSet    *types.TipSet
+	Actor     address.Address
+	StateRoot cid.Cid
+	Reverted  bool
+}
+
+// MessageFilter restricts a MessageStream subscription. A filter with both
+// slices empty matches every message; otherwise a message matches if its
+// sender is in From or its recipient is in To.
+type MessageFilter struct {
+	From []address.Address
+	To   []address.Address
+}
+
+func (f MessageFilter) matches(m *types.Message) bool {
+	if len(f.From) == 0 && len(f.To) == 0 {
+		return true
+	}
+	for _, a := range f.From {
+		if a == m.From {
+			return true
+		}
+	}
+	for _, a := range f.To {
+		if a == m.To {
+			return true
+		}
+	}
+	return false
+}
+
+// MessagesForTipSet loads the messages carried by a tipset. It's supplied by
+// the caller (node/impl/sentinel) since fetching them needs a chain store
+// handle the sentinel package doesn't hold.
+type MessagesForTipSet func(ctx context.Context, ts *types.TipSet) ([]*types.Message, error)
+
+// ActorStateRoots resolves the current state root of each requested actor
+// at ts. It's supplied by the caller for the same reason as
+// MessagesForTipSet.
+type ActorStateRoots func(ctx context.Context, ts *types.TipSet, actors []address.Address) (map[address.Address]cid.Cid, error)
+
+// Hub fans applied/reverted tipsets out to TipSetStream, MessageStream and
+// StateChangeStream subscribers. It implements the TipSetObserver interface
+// expected by events.Events.Observe, the same as IndexingTipSetObserver.
+type Hub struct {
+	getMessages MessagesForTipSet
+	getRoots    ActorStateRoots
+
+	mu       sync.Mutex
+	nextID   int
+	tipsets  map[int]*tipsetSub
+	messages map[int]*messageSub
+	states   map[int]*stateSub
+}
+
+func NewHub(getMessages MessagesForTipSet, getRoots ActorStateRoots) *Hub {
+	return &Hub{
+		getMessages: getMessages,
+		getRoots:    getRoots,
+		tipsets:     map[int]*tipsetSub{},
+		messages:    map[int]*messageSub{},
+		states:      map[int]*stateSub{},
+	}
+}
+
+type tipsetSub struct {
+	ch  chan TipSetEvent
+	lag uint64
+}
+
+type messageSub struct {
+	ch     chan MessageEvent
+	filter MessageFilter
+	lag    uint64
+}
+
+type stateSub struct {
+	ch     chan StateDelta
+	actors []address.Address
+	lag    uint64
+
+	rootsMu   sync.Mutex
+	lastRoots map[address.Address]cid.Cid
+}
+
+// changed reports whether root differs from the last root delivered to s for
+// actor, recording root as the new last-seen value either way. The very
+// first root observed for an actor always counts as changed: there's
+// nothing to compare it against yet, and a new subscriber should get an
+// initial delta rather than silently waiting for the next real change.
+func (s *stateSub) changed(actor address.Address, root cid.Cid) bool {
+	s.rootsMu.Lock()
+	defer s.rootsMu.Unlock()
+
+	if s.lastRoots == nil {
+		s.lastRoots = map[address.Address]cid.Cid{}
+	}
+	last, ok := s.lastRoots[actor]
+	s.lastRoots[actor] = root
+	return !ok || !last.Equals(root)
+}
+
+// TipSetStream registers a new subscriber and returns its event channel.
+// Call the returned cancel func to unsubscribe and release the channel.
+func (h *Hub) TipSetStream() (<-chan TipSetEvent, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := h.nextID
+	h.nextID++
+	sub := &tipsetSub{ch: make(chan TipSetEvent, StreamBufferLen)}
+	h.tipsets[id] = sub
+
+	return sub.ch, func() { h.unsubscribeTipSet(id) }, nil
+}
+
+func (h *Hub) MessageStream(filter MessageFilter) (<-chan MessageEvent, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := h.nextID
+	h.nextID++
+	sub := &messageSub{ch: make(chan MessageEvent, StreamBufferLen), filter: filter}
+	h.messages[id] = sub
+
+	return sub.ch, func() { h.unsubscribeMessage(id) }, nil
+}
+
+func (h *Hub) StateChangeStream(actors []address.Address) (<-chan StateDelta, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := h.nextID
+	h.nextID++
+	sub := &stateSub{ch: make(chan StateDelta, StreamBufferLen), actors: actors}
+	h.states[id] = sub
+
+	return sub.ch, func() { h.unsubscribeState(id) }, nil
+}
+
+// DroppedCounts reports, per stream kind, how many events have been dropped
+// across all of that kind's subscribers because they weren't reading fast
+// enough.
+func (h *Hub) DroppedCounts() (tipsets, messages, states uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.tipsets {
+		tipsets += atomic.LoadUint64(&s.lag)
+	}
+	for _, s := range h.messages {
+		messages += atomic.LoadUint64(&s.lag)
+	}
+	for _, s := range h.states {
+		states += atomic.LoadUint64(&s.lag)
+	}
+	return
+}
+
+func (h *Hub) unsubscribeTipSet(id int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.tipsets, id)
+}
+
+func (h *Hub) unsubscribeMessage(id int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.messages, id)
+}
+
+func (h *Hub) unsubscribeState(id int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.states, id)
+}
+
+func (h *Hub) Apply(ctx context.Context, _, ts *types.TipSet) error {
+	return h.broadcast(ctx, ts, false)
+}
+
+func (h *Hub) Revert(ctx context.Context, _, ts *types.TipSet) error {
+	return h.broadcast(ctx, ts, true)
+}
+
+func (h *Hub) broadcast(ctx context.Context, ts *types.TipSet, reverted bool) error {
+	h.mu.Lock()
+	tipsets := make([]*tipsetSub, 0, len(h.tipsets))
+	for _, s := range h.tipsets {
+		tipsets = append(tipsets, s)
+	}
+	messages := make([]*messageSub, 0, len(h.messages))
+	for _, s := range h.messages {
+		messages = append(messages, s)
+	}
+	states := make([]*stateSub, 0, len(h.states))
+	for _, s := range h.states {
+		states = append(states, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range tipsets {
+		sendTipSetDropOldest(s.ch, TipSetEvent{TipSet: ts, Reverted: reverted}, &s.lag)
+	}
+
+	// Message and state delivery are independent of each other and of the
+	// tipset stream above: a fetch error on one must not stop the others
+	// from being delivered for this tipset.
+	var firstErr error
+
+	if len(messages) > 0 && h.getMessages != nil {
+		msgs, err := h.getMessages(ctx, ts)
+		if err != nil {
+			firstErr = err
+		}
+		for _, m := range msgs {
+			for _, s := range messages {
+				if s.filter.matches(m) {
+					sendMessageDropOldest(s.ch, MessageEvent{TipSet: ts, Message: m, Reverted: reverted}, &s.lag)
+				}
+			}
+		}
+	}
+
+	if len(states) > 0 && h.getRoots != nil {
+		for _, s := range states {
+			roots, err := h.getRoots(ctx, ts, s.actors)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			for actor, root := range roots {
+				if !s.changed(actor, root) {
+					continue
+				}
+				sendStateDropOldest(s.ch, StateDelta{TipSet: ts, Actor: actor, StateRoot: root, Reverted: reverted}, &s.lag)
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// The three sendXDropOldest helpers below all implement the same
+// non-blocking, drop-oldest-on-full send; they're duplicated per channel
+// type rather than made generic to match the rest of this (pre-generics)
+// codebase.
+
+func sendTipSetDropOldest(ch chan TipSetEvent, v TipSetEvent, lag *uint64) {
+	select {
+	case ch <- v:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+		atomic.AddUint64(lag, 1)
+	default:
+	}
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+func sendMessageDropOldest(ch chan MessageEvent, v MessageEvent, lag *uint64) {
+	select {
+	case ch <- v:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+		atomic.AddUint64(lag, 1)
+	default:
+	}
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+func sendStateDropOldest(ch chan StateDelta, v StateDelta, lag *uint64) {
+	select {
+	case ch <- v:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+		atomic.AddUint64(lag, 1)
+	default:
+	}
+	select {
+	case ch <- v:
+	default:
+	}
+}
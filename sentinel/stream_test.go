@@ -0,0 +1,118 @@
+package sentinel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func mustCid(t *testing.T, s string) cid.Cid {
+	t.Helper()
+	h, err := mh.Sum([]byte(s), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.Raw, h)
+}
+
+func TestHubTipSetStreamDropsOldestWhenFull(t *testing.T) {
+	h := NewHub(nil, nil)
+
+	ch, cancel, err := h.TipSetStream()
+	require.NoError(t, err)
+	defer cancel()
+
+	ts := &types.TipSet{}
+
+	for i := 0; i < StreamBufferLen+5; i++ {
+		require.NoError(t, h.Apply(context.Background(), nil, ts))
+	}
+
+	tipsets, messages, states := h.DroppedCounts()
+	require.EqualValues(t, 5, tipsets)
+	require.Zero(t, messages)
+	require.Zero(t, states)
+
+	// The channel stays full at StreamBufferLen; draining it confirms no
+	// event delivery was lost beyond the ones counted as dropped.
+	drained := 0
+	for {
+		select {
+		case <-ch:
+			drained++
+			continue
+		default:
+		}
+		break
+	}
+	require.Equal(t, StreamBufferLen, drained)
+}
+
+func TestHubUnsubscribeStopsDropAccounting(t *testing.T) {
+	h := NewHub(nil, nil)
+
+	_, cancel, err := h.TipSetStream()
+	require.NoError(t, err)
+	cancel()
+
+	ts := &types.TipSet{}
+	for i := 0; i < StreamBufferLen+5; i++ {
+		require.NoError(t, h.Apply(context.Background(), nil, ts))
+	}
+
+	tipsets, _, _ := h.DroppedCounts()
+	require.Zero(t, tipsets)
+}
+
+func TestHubStateChangeStreamOnlyEmitsOnRootChange(t *testing.T) {
+	actor, err := address.NewFromString("f01000")
+	require.NoError(t, err)
+
+	rootA := mustCid(t, "a")
+	rootB := mustCid(t, "b")
+	root := rootA
+
+	getRoots := func(_ context.Context, _ *types.TipSet, actors []address.Address) (map[address.Address]cid.Cid, error) {
+		return map[address.Address]cid.Cid{actors[0]: root}, nil
+	}
+
+	h := NewHub(nil, getRoots)
+
+	ch, cancel, err := h.StateChangeStream([]address.Address{actor})
+	require.NoError(t, err)
+	defer cancel()
+
+	ts := &types.TipSet{}
+
+	// First observation: nothing to compare against yet, so it's delivered.
+	require.NoError(t, h.Apply(context.Background(), nil, ts))
+	select {
+	case d := <-ch:
+		require.True(t, d.StateRoot.Equals(rootA))
+	default:
+		t.Fatal("expected initial StateDelta")
+	}
+
+	// Same root again: no real change, nothing should be delivered.
+	require.NoError(t, h.Apply(context.Background(), nil, ts))
+	select {
+	case d := <-ch:
+		t.Fatalf("unexpected StateDelta for unchanged root: %+v", d)
+	default:
+	}
+
+	// Root actually changes: delivered.
+	root = rootB
+	require.NoError(t, h.Apply(context.Background(), nil, ts))
+	select {
+	case d := <-ch:
+		require.True(t, d.StateRoot.Equals(rootB))
+	default:
+		t.Fatal("expected StateDelta for changed root")
+	}
+}